@@ -0,0 +1,206 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// fastHandlers mirrors handlers but is wired for fasthttp's RequestCtx
+// instead of net/http, so the bulk endpoints can be served without the
+// per-request header/response allocations net/http makes that otherwise
+// show up in pprof and distort throughput measurements.
+type fastHandlers struct {
+	EnableCORS    bool
+	BytesServed   *uint64
+	BytesReceived *uint64
+	PayloadSource PayloadSource
+}
+
+// BulkFastHTTPHandlers returns path, handler tuples with the provided
+// prefix, backed by fasthttp instead of net/http.
+func BulkFastHTTPHandlers(prefix string, EnableCORS bool) map[string]fasthttp.RequestHandler {
+	h := &fastHandlers{EnableCORS: EnableCORS}
+	return map[string]fasthttp.RequestHandler{
+		prefix + "/small": h.smallHandler,
+		prefix + "/large": h.largeHandler,
+		prefix + "/slurp": h.slurpHandler,
+	}
+}
+
+// CountingBulkFastHTTPHandlers is the fasthttp analogue of
+// CountingBulkHandlers: it wires the same atomic byte counters through so
+// throughput metrics stay transport-agnostic.
+func CountingBulkFastHTTPHandlers(prefix string, EnableCORS bool, bytesServed, bytesReceived *uint64) map[string]fasthttp.RequestHandler {
+	return CountingBulkFastHTTPHandlersWithPayloadSource(prefix, EnableCORS, bytesServed, bytesReceived, nil)
+}
+
+// CountingBulkFastHTTPHandlersWithPayloadSource is the
+// CountingBulkFastHTTPHandlers variant that lets the caller pick the
+// PayloadSource driving /small and /large, e.g. to wire up
+// Server.PayloadSource the way CountingBulkHandlersWithPayloadSource does
+// for the net/http transport. A nil source falls back to the original
+// in-memory buffer.
+func CountingBulkFastHTTPHandlersWithPayloadSource(prefix string, EnableCORS bool, bytesServed, bytesReceived *uint64, source PayloadSource) map[string]fasthttp.RequestHandler {
+	h := &fastHandlers{EnableCORS: EnableCORS, BytesServed: bytesServed, BytesReceived: bytesReceived, PayloadSource: source}
+	return map[string]fasthttp.RequestHandler{
+		prefix + "/small": h.smallHandler,
+		prefix + "/large": h.largeHandler,
+		prefix + "/slurp": h.slurpHandler,
+	}
+}
+
+func setCorsFastHTTP(h *fasthttp.ResponseHeader) {
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Headers", "*")
+}
+
+func setNoPublicCacheFastHTTP(h *fasthttp.ResponseHeader) {
+	h.Set("Proxy-Cache-Control", "max-age=604800, public")
+	h.Set("Cache-Control", "no-store, must-revalidate, private, max-age=0")
+}
+
+func (h *fastHandlers) smallHandler(ctx *fasthttp.RequestCtx) {
+	startTime := time.Now()
+	defer func() {
+		pH.WithLabelValues("smallHandler", "complete", "count").Observe(time.Since(startTime).Seconds())
+	}()
+	pC.WithLabelValues("smallHandler", string(ctx.Method()), "count").Inc()
+
+	if !ctx.IsGet() && !ctx.IsHead() {
+		pC.WithLabelValues("smallHandler", "StatusMethodNotAllowed", "count").Inc()
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx.Response.Header.SetContentLength(int(smallContentLength))
+	ctx.SetContentType("application/octet-stream")
+
+	if h.EnableCORS {
+		setCorsFastHTTP(&ctx.Response.Header)
+	}
+
+	h.chunkedBodyWriter(ctx, smallContentLength)
+}
+
+func (h *fastHandlers) largeHandler(ctx *fasthttp.RequestCtx) {
+	inFlightTransfers.Add(1)
+	defer inFlightTransfers.Add(-1)
+
+	startTime := time.Now()
+	defer func() {
+		pH.WithLabelValues("largeHandler", "complete", "count").Observe(time.Since(startTime).Seconds())
+	}()
+	pC.WithLabelValues("largeHandler", string(ctx.Method()), "count").Inc()
+
+	if !ctx.IsGet() && !ctx.IsHead() {
+		pC.WithLabelValues("largeHandler", "StatusMethodNotAllowed", "count").Inc()
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx.Response.Header.SetContentLength(int(largeContentLength))
+	ctx.SetContentType("application/octet-stream")
+
+	if h.EnableCORS {
+		setCorsFastHTTP(&ctx.Response.Header)
+	}
+
+	if !ctx.IsGet() {
+		return
+	}
+
+	h.chunkedBodyWriter(ctx, largeContentLength)
+}
+
+// byteCountingReader wraps an io.Reader to tally bytes actually read into
+// *counter, mirroring how countingDiscard tracks slurpHandler's uploads.
+type byteCountingReader struct {
+	r       io.Reader
+	counter *uint64
+}
+
+func (c byteCountingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		atomic.AddUint64(c.counter, uint64(n))
+		pC.WithLabelValues("chunkedBodyWriter", "n", "count").Add(float64(n))
+	}
+	return n, err
+}
+
+// chunkedBodyWriter streams contentLength bytes of h.PayloadSource to the
+// client, or of the repeated buffed buffer when PayloadSource is nil.
+// It uses Response.SetBodyStream with an explicit size rather than
+// SetBodyStreamWriter: SetBodyStreamWriter passes a stream size of -1
+// internally, which makes fasthttp treat the response as unknown-length
+// and serve it chunked, silently discarding the Content-Length the callers
+// above just set and breaking parity with the net/http transport.
+func (h *fastHandlers) chunkedBodyWriter(ctx *fasthttp.RequestCtx, contentLength int64) {
+	startTime := time.Now()
+	defer func() {
+		pH.WithLabelValues("chunkedBodyWriter", "complete", "count").Observe(time.Since(startTime).Seconds())
+	}()
+	pC.WithLabelValues("chunkedBodyWriter", "start", "count").Inc()
+
+	source := h.PayloadSource
+	if source == nil {
+		source = memoryPayloadSource{}
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+	stream := byteCountingReader{r: io.LimitReader(source.Reader(), contentLength), counter: h.BytesServed}
+	ctx.Response.SetBodyStream(stream, int(contentLength))
+}
+
+// slurpHandler reads the request body and discards it, mirroring
+// handlers.slurpHandler's use of countingDiscard so upload throughput is
+// tracked the same way regardless of transport.
+func (h *fastHandlers) slurpHandler(ctx *fasthttp.RequestCtx) {
+	inFlightTransfers.Add(1)
+	defer inFlightTransfers.Add(-1)
+
+	startTime := time.Now()
+	defer func() {
+		pH.WithLabelValues("slurpHandler", "complete", "count").Observe(time.Since(startTime).Seconds())
+	}()
+	pC.WithLabelValues("slurpHandler", "start", "count").Inc()
+
+	ctx.SetContentType("application/octet-stream")
+	setNoPublicCacheFastHTTP(&ctx.Response.Header)
+
+	if h.EnableCORS {
+		setCorsFastHTTP(&ctx.Response.Header)
+	}
+
+	if _, err := io.Copy(countingDiscard{byteCounter: h.BytesReceived}, ctx.RequestBodyStream()); err != nil {
+		pC.WithLabelValues("slurpHandler", "Copy", "error").Inc()
+		ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		return
+	}
+
+	ctx.SetStatusCode(fasthttp.StatusOK)
+}
+
+// ListenAndServeFastHTTP serves the bulk endpoints over fasthttp on
+// PublicPort, sharing the same atomic byte counters as the net/http
+// transport so throughput metrics stay transport-agnostic.
+func (m *Server) ListenAndServeFastHTTP() error {
+	handlers := CountingBulkFastHTTPHandlersWithPayloadSource(m.ContextPath, m.EnableCORS, &m.BytesServed, &m.BytesReceived, m.PayloadSource)
+
+	router := func(ctx *fasthttp.RequestCtx) {
+		handler, ok := handlers[string(ctx.Path())]
+		if !ok {
+			ctx.SetStatusCode(fasthttp.StatusNotFound)
+			return
+		}
+		handler(ctx)
+	}
+
+	return fasthttp.ListenAndServe(fmt.Sprintf(":%d", m.PublicPort), router)
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"io"
+	"testing"
+)
+
+// TestChunkedBodyWriterZeroAlloc guards the zero-alloc claim
+// BulkFastHTTPHandlers is built on: the repeatingReader and
+// byteCountingReader that back chunkedBodyWriter's stream must not
+// allocate per Read, since fasthttp calls Read repeatedly while copying
+// the body into the connection.
+func TestChunkedBodyWriterZeroAlloc(t *testing.T) {
+	var served uint64
+	stream := byteCountingReader{
+		r:       io.LimitReader(&repeatingReader{}, int64(1000)*chunkSize),
+		counter: &served,
+	}
+	buf := make([]byte, chunkSize)
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		if _, err := stream.Read(buf); err != nil && err != io.EOF {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected zero allocations per Read, got %v", allocs)
+	}
+}
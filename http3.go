@@ -0,0 +1,56 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/quic-go/quic-go/http3"
+)
+
+var pProto = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "counters",
+		Name:      "networkQualityd_proto",
+		Help:      "requests by transport protocol, so h2 and h3 throughput/responsiveness can be compared",
+	},
+	[]string{"proto", "path"},
+)
+
+// requestProto reports the transport a request arrived over: quic-go/http3
+// sets ProtoMajor to 3, so any other value is the TCP (h2/h1.1) path. This
+// is read directly off the request rather than baked in by whichever mux
+// registered the handler, so every handlers/ConfigHandler caller gets a
+// correct proto label for free regardless of how it wires up routes.
+func requestProto(r *http.Request) string {
+	if r.ProtoMajor == 3 {
+		return "h3"
+	}
+	return "h2"
+}
+
+// ListenAndServeH3 serves /small, /large, /slurp, and /config over QUIC on
+// PublicPort (UDP), sharing the same handlers struct and atomic byte
+// counters as the TCP path so H3 is a real alternative transport rather
+// than just the Alt-Svc advertisement ConfigHandler already sends.
+func (m *Server) ListenAndServeH3(tlsConfig *tls.Config) error {
+	bulk := CountingBulkHandlersWithPayloadSource(m.ContextPath, m.EnableCORS, &m.BytesServed, &m.BytesReceived, m.PayloadSource)
+
+	mux := http.NewServeMux()
+	for path, handler := range bulk {
+		mux.HandleFunc(path, handler)
+	}
+	mux.HandleFunc(m.ContextPath+"/config", m.ConfigHandler)
+
+	h3srv := &http3.Server{
+		Addr:      fmt.Sprintf(":%d", m.PublicPort),
+		TLSConfig: tlsConfig,
+		Handler:   mux,
+	}
+
+	return h3srv.ListenAndServe()
+}
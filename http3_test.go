@@ -0,0 +1,26 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRequestProto(t *testing.T) {
+	cases := []struct {
+		protoMajor int
+		want       string
+	}{
+		{protoMajor: 1, want: "h2"},
+		{protoMajor: 2, want: "h2"},
+		{protoMajor: 3, want: "h3"},
+	}
+
+	for _, c := range cases {
+		r := &http.Request{ProtoMajor: c.protoMajor}
+		if got := requestProto(r); got != c.want {
+			t.Errorf("requestProto(ProtoMajor=%d) = %q, want %q", c.protoMajor, got, c.want)
+		}
+	}
+}
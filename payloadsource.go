@@ -0,0 +1,144 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+)
+
+// PayloadSource produces the bytes streamed for the /small and /large
+// download path. Reader returns a fresh io.Reader for a single request;
+// implementations that wrap a real file (devZeroPayloadSource) return the
+// *os.File itself so io.CopyN can hit the ResponseWriter's sendfile/splice
+// fast path instead of forcing every byte through a userspace buffer.
+type PayloadSource interface {
+	Reader() io.Reader
+}
+
+// memoryPayloadSource is the original in-memory payload: it repeats the
+// package-level buffed buffer, the same bytes chunkedBodyWriter always
+// wrote.
+type memoryPayloadSource struct{}
+
+func (memoryPayloadSource) Reader() io.Reader {
+	return &repeatingReader{}
+}
+
+// repeatingReader cycles through buffed indefinitely.
+type repeatingReader struct {
+	pos int
+}
+
+func (r *repeatingReader) Read(p []byte) (int, error) {
+	n := copy(p, buffed[r.pos:])
+	r.pos += n
+	if r.pos >= len(buffed) {
+		r.pos = 0
+	}
+	return n, nil
+}
+
+// fileBackedPayloadSource is implemented by PayloadSources that can hand
+// back the *os.File backing them, so chunkedBodyWriter can copy straight
+// into the ResponseWriter and let its sendfile/splice fast path take over
+// instead of going through the chunked read/write loop.
+//
+// chunkedBodyWriter calls File() once per request and reads from the
+// returned *os.File with no per-request offset isolation (no Seek, no
+// dup'd fd), and concurrent /large requests hold no lock around it. An
+// implementation must therefore be safe to read concurrently from
+// multiple goroutines without regard to file position -- i.e. its output
+// must not depend on the current seek offset, the way /dev/zero's doesn't.
+// A source backed by a regular file (where position matters) must open or
+// dup a fresh *os.File per File() call rather than returning a shared one.
+type fileBackedPayloadSource interface {
+	File() *os.File
+}
+
+// devZeroPayloadSource reads from /dev/zero so io.CopyN can pass the
+// underlying *os.File straight to the destination, letting the kernel
+// sendfile/splice the response instead of copying through Go's stack. A
+// single shared *os.File is safe here only because /dev/zero's output
+// never depends on the file's read offset; see fileBackedPayloadSource.
+type devZeroPayloadSource struct {
+	f *os.File
+}
+
+func newDevZeroPayloadSource() (*devZeroPayloadSource, error) {
+	f, err := os.Open("/dev/zero")
+	if err != nil {
+		return nil, fmt.Errorf("opening /dev/zero: %w", err)
+	}
+	return &devZeroPayloadSource{f: f}, nil
+}
+
+// File returns the shared /dev/zero fd. Safe to share across concurrent
+// requests because /dev/zero ignores file position; see
+// fileBackedPayloadSource for the contract this relies on.
+func (d *devZeroPayloadSource) File() *os.File {
+	return d.f
+}
+
+func (d *devZeroPayloadSource) Reader() io.Reader {
+	return d.f
+}
+
+func (d *devZeroPayloadSource) Close() error {
+	return d.f.Close()
+}
+
+// prngPayloadSource emits a deterministic pseudo-random stream, seeded
+// once at construction, so caches and middleboxes that dedupe or collapse
+// repetitive bodies can't short-circuit the measurement the way they could
+// with memoryPayloadSource's repeated buffer.
+type prngPayloadSource struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newPRNGPayloadSource(seed int64) *prngPayloadSource {
+	return &prngPayloadSource{rng: rand.New(rand.NewSource(seed))}
+}
+
+func (p *prngPayloadSource) Reader() io.Reader {
+	return prngReader{p}
+}
+
+type prngReader struct {
+	source *prngPayloadSource
+}
+
+func (r prngReader) Read(p []byte) (int, error) {
+	r.source.mu.Lock()
+	defer r.source.mu.Unlock()
+	return r.source.rng.Read(p)
+}
+
+// defaultPRNGSeed is used by NewPayloadSource("prng", ...) so repeated runs
+// without an explicit seed are still reproducible between client and
+// server when comparing captures.
+const defaultPRNGSeed = 42
+
+// NewPayloadSource builds a PayloadSource by name, for wiring up a CLI flag
+// (e.g. "-payload-source=memory|devzero|prng") in whatever binary embeds
+// this package. seed only applies to "prng"; pass 0 to use
+// defaultPRNGSeed.
+func NewPayloadSource(kind string, seed int64) (PayloadSource, error) {
+	switch kind {
+	case "", "memory":
+		return memoryPayloadSource{}, nil
+	case "devzero":
+		return newDevZeroPayloadSource()
+	case "prng":
+		if seed == 0 {
+			seed = defaultPRNGSeed
+		}
+		return newPRNGPayloadSource(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown payload source %q", kind)
+	}
+}
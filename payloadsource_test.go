@@ -0,0 +1,77 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRepeatingReaderWraparound(t *testing.T) {
+	r := &repeatingReader{}
+	buf := make([]byte, len(buffed)/4)
+
+	// Read exactly len(buffed) bytes across multiple calls and confirm pos
+	// wraps back to 0 instead of indexing past the end of buffed.
+	for i := 0; i < 4; i++ {
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != len(buf) {
+			t.Fatalf("read %d bytes, want %d", n, len(buf))
+		}
+	}
+	if r.pos != 0 {
+		t.Fatalf("expected pos to wrap to 0 after a full cycle, got %d", r.pos)
+	}
+
+	// One more read should succeed identically instead of returning 0
+	// bytes/EOF, proving the stream actually repeats.
+	n, err := r.Read(buf)
+	if err != nil || n != len(buf) {
+		t.Fatalf("Read after wraparound = (%d, %v), want (%d, nil)", n, err, len(buf))
+	}
+}
+
+func TestNewPayloadSourceUnknownKind(t *testing.T) {
+	if _, err := NewPayloadSource("bogus", 0); err == nil {
+		t.Fatal("expected an error for an unknown payload source kind, got nil")
+	}
+}
+
+func TestNewPayloadSourceMemory(t *testing.T) {
+	for _, kind := range []string{"", "memory"} {
+		src, err := NewPayloadSource(kind, 0)
+		if err != nil {
+			t.Fatalf("NewPayloadSource(%q, 0): unexpected error: %s", kind, err)
+		}
+		if _, ok := src.(memoryPayloadSource); !ok {
+			t.Fatalf("NewPayloadSource(%q, 0) = %T, want memoryPayloadSource", kind, src)
+		}
+	}
+}
+
+func TestPRNGPayloadSourceDeterministic(t *testing.T) {
+	a, err := NewPayloadSource("prng", 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := NewPayloadSource("prng", 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	bufA := make([]byte, 256)
+	bufB := make([]byte, 256)
+	if _, err := io.ReadFull(a.Reader(), bufA); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := io.ReadFull(b.Reader(), bufB); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !bytes.Equal(bufA, bufB) {
+		t.Fatal("two prng sources seeded identically produced different streams")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// inFlightTransfers counts /large and /slurp transfers currently running on
+// this process, incremented at the top of largeHandler/slurpHandler and
+// decremented on exit, so ProbeHandler can tell whether a sample was taken
+// under load.
+var inFlightTransfers atomic.Int64
+
+var probeLatency = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Subsystem: "histograms",
+		Name:      "networkQualityd_probe_latency_seconds",
+		Help:      "time from ProbeHandler entry to first byte written, labeled by whether a bulk transfer was in flight",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"inflight"},
+)
+
+var probeRPM = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "gauges",
+		Name:      "networkQualityd_probe_rpm",
+		Help:      "round-trips per minute implied by the most recent /probe sample, labeled by whether a bulk transfer was in flight",
+	},
+	[]string{"inflight"},
+)
+
+// ProbeHandler is a dedicated low-latency path for measuring
+// responsiveness (Apple's round-trips-per-minute, or RPM, metric)
+// independently of the bulk download/upload endpoints. It returns a fixed
+// ~1-byte response and records, in probeLatency, the wall-clock time from
+// entry to first byte written, so the server can self-report RPM without
+// an external client correlating timings across requests.
+func (m *Server) ProbeHandler(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
+	pC.WithLabelValues("ProbeHandler", r.Method, "count").Inc()
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		pC.WithLabelValues("ProbeHandler", "StatusMethodNotAllowed", "count").Inc()
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Length", "1")
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	if m.EnableCORS {
+		setCors(w.Header())
+	}
+
+	inflight := "false"
+	if inFlightTransfers.Load() > 0 {
+		inflight = "true"
+	}
+
+	if _, err := w.Write([]byte{'P'}); err != nil {
+		pC.WithLabelValues("ProbeHandler", "Write", "error").Inc()
+		return
+	}
+
+	latency := time.Since(startTime)
+	probeLatency.WithLabelValues(inflight).Observe(latency.Seconds())
+	if latency > 0 {
+		probeRPM.WithLabelValues(inflight).Set(60 / latency.Seconds())
+	}
+}
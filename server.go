@@ -78,6 +78,7 @@ type handlers struct {
 	EnableCORS    bool
 	BytesServed   *uint64
 	BytesReceived *uint64
+	PayloadSource PayloadSource
 }
 
 // BulkHandlers returns path, handler tuples with the provided prefix.
@@ -99,6 +100,19 @@ func CountingBulkHandlers(prefix string, EnableCORS bool, bytesServed, bytesRece
 	}
 }
 
+// CountingBulkHandlersWithPayloadSource is the CountingBulkHandlers variant
+// that lets the caller pick the PayloadSource driving /small and /large,
+// e.g. to wire up Server.PayloadSource. A nil source falls back to the
+// original in-memory buffer.
+func CountingBulkHandlersWithPayloadSource(prefix string, EnableCORS bool, bytesServed, bytesReceived *uint64, source PayloadSource) map[string]http.HandlerFunc {
+	h := &handlers{EnableCORS: EnableCORS, BytesServed: bytesServed, BytesReceived: bytesReceived, PayloadSource: source}
+	return map[string]http.HandlerFunc{
+		prefix + "/small": h.smallHandler,
+		prefix + "/large": h.largeHandler,
+		prefix + "/slurp": h.slurpHandler,
+	}
+}
+
 // A Server defines parameters for running a network quality server.
 type Server struct {
 	PublicPort     int
@@ -110,6 +124,10 @@ type Server struct {
 	BytesServed    uint64
 	BytesReceived  uint64
 
+	// PayloadSource picks what bytes /small and /large serve. A nil value
+	// falls back to the original in-memory repeated buffer.
+	PayloadSource PayloadSource
+
 	generatedConfig []byte
 	once            sync.Once
 }
@@ -164,6 +182,9 @@ func (m *Server) generateConfig() {
 		SmallHTTPSDownloadURL string `json:"small_https_download_url"`
 		LargeHTTPSDownloadURL string `json:"large_https_download_url"`
 		HTTPSUploadURL        string `json:"https_upload_url"`
+		H3SmallDownloadURL    string `json:"h3_small_download_url,omitempty"`
+		H3LargeDownloadURL    string `json:"h3_large_download_url,omitempty"`
+		H3UploadURL           string `json:"h3_upload_url,omitempty"`
 	}{
 		SmallDownloadURL:      m.generateSmallDownloadURL(),
 		LargeDownloadURL:      m.generateLargeDownloadURL(),
@@ -173,6 +194,15 @@ func (m *Server) generateConfig() {
 		HTTPSUploadURL:        m.generateUploadURL(),
 	}
 
+	// H3 listens on the same PublicPort over UDP, so network-quality
+	// clients that prefer QUIC can pick up a distinct URL set rather than
+	// having to infer H3 support from the Alt-Svc header alone.
+	if m.EnableH3AltSvc {
+		urls.H3SmallDownloadURL = m.generateSmallDownloadURL()
+		urls.H3LargeDownloadURL = m.generateLargeDownloadURL()
+		urls.H3UploadURL = m.generateUploadURL()
+	}
+
 	resp := struct {
 		Version int         `json:"version"`
 		Urls    interface{} `json:"urls"`
@@ -190,6 +220,8 @@ func (m *Server) generateConfig() {
 }
 
 func (m *Server) ConfigHandler(w http.ResponseWriter, r *http.Request) {
+	pProto.WithLabelValues(requestProto(r), r.URL.Path).Inc()
+
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
@@ -225,6 +257,8 @@ func (m *Server) generateUploadURL() string {
 }
 
 func (h *handlers) smallHandler(w http.ResponseWriter, r *http.Request) {
+	pProto.WithLabelValues(requestProto(r), r.URL.Path).Inc()
+
 	startTime := time.Now()
 	defer func() {
 		pH.WithLabelValues("smallHandler", "complete", "count").Observe(time.Since(startTime).Seconds())
@@ -250,6 +284,10 @@ func (h *handlers) smallHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handlers) largeHandler(w http.ResponseWriter, r *http.Request) {
+	pProto.WithLabelValues(requestProto(r), r.URL.Path).Inc()
+
+	inFlightTransfers.Add(1)
+	defer inFlightTransfers.Add(-1)
 
 	startTime := time.Now()
 	defer func() {
@@ -290,32 +328,64 @@ func (h *handlers) chunkedBodyWriter(w http.ResponseWriter, contentLength int64)
 
 	w.WriteHeader(http.StatusOK)
 
-	n := contentLength
-	for n > 0 {
-		if n >= chunkSize {
-			n -= chunkSize
-			atomic.AddUint64(h.BytesServed, uint64(chunkSize))
-			pC.WithLabelValues("chunkedBodyWriter", "chunkSize", "count").Add(float64(chunkSize))
-
-			if _, err := w.Write(buffed); err != nil {
-				pC.WithLabelValues("chunkedBodyWriter", "chunkedWrite", "error").Inc()
-				return err
-			}
-			continue
-		}
+	source := h.PayloadSource
+	if source == nil {
+		source = memoryPayloadSource{}
+	}
 
+	// A file-backed source (/dev/zero) is copied straight into w so
+	// ResponseWriter's sendfile/splice fast path can take over; wrapping
+	// it first would hide the *os.File and force a userspace copy. That
+	// trades the incremental BytesServed updates below for a single
+	// update once the whole transfer completes.
+	if fb, ok := source.(fileBackedPayloadSource); ok {
+		n, err := io.CopyN(w, fb.File(), contentLength)
 		atomic.AddUint64(h.BytesServed, uint64(n))
 		pC.WithLabelValues("chunkedBodyWriter", "n", "count").Add(float64(n))
-		if _, err := w.Write(buffed[:n]); err != nil {
+		if err != nil {
 			pC.WithLabelValues("chunkedBodyWriter", "Write", "error").Inc()
 			return err
 		}
-		break
+		return nil
+	}
+
+	r := source.Reader()
+	bufp := chunkBufferPool.Get().(*[]byte)
+	defer chunkBufferPool.Put(bufp)
+	buf := *bufp
+
+	n := contentLength
+	for n > 0 {
+		sz := chunkSize
+		if n < sz {
+			sz = n
+		}
+		read, err := io.ReadFull(r, buf[:sz])
+		if read > 0 {
+			atomic.AddUint64(h.BytesServed, uint64(read))
+			pC.WithLabelValues("chunkedBodyWriter", "n", "count").Add(float64(read))
+			if _, werr := w.Write(buf[:read]); werr != nil {
+				pC.WithLabelValues("chunkedBodyWriter", "Write", "error").Inc()
+				return werr
+			}
+		}
+		if err != nil {
+			pC.WithLabelValues("chunkedBodyWriter", "Read", "error").Inc()
+			return err
+		}
+		n -= int64(read)
 	}
 
 	return nil
 }
 
+var chunkBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, chunkSize)
+		return &b
+	},
+}
+
 // setNoPublicCache tells the proxy to cache the content and the user
 // that it can't be cached. It requires the proxy cache to be configured
 // to use the Proxy-Cache-Control header
@@ -327,6 +397,10 @@ func setNoPublicCache(h http.Header) {
 // slurpHandler reads the post request and returns JSON with bytes
 // read and how long it took
 func (h *handlers) slurpHandler(w http.ResponseWriter, r *http.Request) {
+	pProto.WithLabelValues(requestProto(r), r.URL.Path).Inc()
+
+	inFlightTransfers.Add(1)
+	defer inFlightTransfers.Add(-1)
 
 	startTime := time.Now()
 	defer func() {
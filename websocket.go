@@ -0,0 +1,142 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// errBadOrigin is returned by wsHandlers.handshake when EnableCORS is false
+// and the request's Origin header doesn't match the request Host, mirroring
+// the same-origin restriction setCors lifts for the net/http handlers.
+var errBadOrigin = errors.New("goserver: websocket origin does not match host")
+
+// wsControlFrame is the JSON control message a client sends immediately
+// after the /ws handshake to select what the connection does next.
+type wsControlFrame struct {
+	Mode  string `json:"mode"`
+	Bytes int64  `json:"bytes"`
+}
+
+type wsHandlers struct {
+	EnableCORS    bool
+	BytesServed   *uint64
+	BytesReceived *uint64
+}
+
+// WebSocketHandlers returns path, handler tuples with the provided prefix
+// for the /ws bulk-transfer endpoint.
+func WebSocketHandlers(prefix string, EnableCORS bool) map[string]http.HandlerFunc {
+	h := &wsHandlers{EnableCORS: EnableCORS}
+	return map[string]http.HandlerFunc{
+		prefix + "/ws": h.serveWS,
+	}
+}
+
+// CountingWebSocketHandlers is the WebSocketHandlers analogue of
+// CountingBulkHandlers: it wires the same atomic byte counters through so
+// /ws throughput is tracked alongside the request/response endpoints.
+func CountingWebSocketHandlers(prefix string, EnableCORS bool, bytesServed, bytesReceived *uint64) map[string]http.HandlerFunc {
+	h := &wsHandlers{EnableCORS: EnableCORS, BytesServed: bytesServed, BytesReceived: bytesReceived}
+	return map[string]http.HandlerFunc{
+		prefix + "/ws": h.serveWS,
+	}
+}
+
+func (h *wsHandlers) serveWS(w http.ResponseWriter, r *http.Request) {
+	srv := websocket.Server{
+		Handshake: h.handshake,
+		Handler:   websocket.Handler(h.handleConn),
+	}
+	srv.ServeHTTP(w, r)
+}
+
+// handshake restricts the upgrade to same-origin requests unless EnableCORS
+// is set, the same policy setCors expresses for the other endpoints.
+func (h *wsHandlers) handshake(config *websocket.Config, r *http.Request) error {
+	if h.EnableCORS {
+		return nil
+	}
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil || origin.Host != r.Host {
+		return errBadOrigin
+	}
+	return nil
+}
+
+// handleConn implements the /ws framed protocol: a JSON control frame
+// (wsControlFrame) selects whether the server streams bytes down,
+// reads+discards bytes up, or does both concurrently on the same
+// connection, mirroring the workhorse-style channel adapter pattern.
+func (h *wsHandlers) handleConn(conn *websocket.Conn) {
+	startTime := time.Now()
+	defer func() {
+		pH.WithLabelValues("wsHandler", "complete", "count").Observe(time.Since(startTime).Seconds())
+		conn.Close()
+	}()
+	pC.WithLabelValues("wsHandler", "start", "count").Inc()
+
+	var ctrl wsControlFrame
+	if err := websocket.JSON.Receive(conn, &ctrl); err != nil {
+		pC.WithLabelValues("wsHandler", "Receive", "error").Inc()
+		log.Printf("could not read ws control frame: %s", err)
+		return
+	}
+
+	switch ctrl.Mode {
+	case "down":
+		h.wsDown(conn, ctrl.Bytes)
+	case "up":
+		h.wsUp(conn, ctrl.Bytes)
+	case "bidi":
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); h.wsDown(conn, ctrl.Bytes) }()
+		go func() { defer wg.Done(); h.wsUp(conn, ctrl.Bytes) }()
+		wg.Wait()
+	default:
+		pC.WithLabelValues("wsHandler", "unknownMode", "error").Inc()
+		log.Printf("unknown ws mode: %q", ctrl.Mode)
+	}
+}
+
+// wsDown streams n bytes of buffed to conn in binary frames, the payload
+// chunkedBodyWriter uses for /large when no PayloadSource is set. It sends via websocket.Message
+// rather than conn.Write directly: Conn.Write tags frames with
+// conn.PayloadType, which hybi defaults to TextFrame, and a real client
+// must reject a binary payload arriving in a text frame. Message.Send
+// marshals a []byte as BinaryFrame explicitly.
+func (h *wsHandlers) wsDown(conn *websocket.Conn, n int64) {
+	for n > 0 {
+		chunk := buffed
+		if n < chunkSize {
+			chunk = buffed[:n]
+		}
+		if err := websocket.Message.Send(conn, chunk); err != nil {
+			pC.WithLabelValues("wsDown", "Write", "error").Inc()
+			return
+		}
+		atomic.AddUint64(h.BytesServed, uint64(len(chunk)))
+		pC.WithLabelValues("wsDown", "chunk", "count").Add(float64(len(chunk)))
+		n -= int64(len(chunk))
+	}
+}
+
+// wsUp reads n bytes from conn and discards them via countingDiscard, the
+// same path slurpHandler uses for uploads.
+func (h *wsHandlers) wsUp(conn *websocket.Conn, n int64) {
+	if _, err := io.Copy(countingDiscard{byteCounter: h.BytesReceived}, io.LimitReader(conn, n)); err != nil {
+		pC.WithLabelValues("wsUp", "Copy", "error").Inc()
+	}
+}
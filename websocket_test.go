@@ -0,0 +1,35 @@
+// Copyright (c) 2021-2023 Apple Inc. Licensed under MIT License.
+
+package goserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestHandshakeSameOrigin(t *testing.T) {
+	h := &wsHandlers{}
+	// The server's hybi handshaker sets Version before calling handshake;
+	// websocket.Origin only reads the Origin header for that version.
+	config := &websocket.Config{Version: websocket.ProtocolVersionHybi13}
+
+	sameOrigin := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	sameOrigin.Header.Set("Origin", "http://example.com")
+	if err := h.handshake(config, sameOrigin); err != nil {
+		t.Fatalf("same-origin handshake rejected: %s", err)
+	}
+
+	crossOrigin := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	crossOrigin.Header.Set("Origin", "http://evil.example")
+	if err := h.handshake(config, crossOrigin); err == nil {
+		t.Fatal("expected cross-origin handshake to be rejected when EnableCORS is false")
+	}
+
+	h.EnableCORS = true
+	if err := h.handshake(config, crossOrigin); err != nil {
+		t.Fatalf("expected EnableCORS to allow cross-origin handshake, got: %s", err)
+	}
+}